@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
 	"sync"
 	"time"
 )
@@ -12,36 +18,225 @@ const (
 	Pending Status = "Pending"
 	Passed         = "Passed"
 	Failed         = "Failed"
+	// Aborted marks a Dag (never a Vertex) whose ExecuteWithContext exited
+	// early because ctx was canceled or timed out, rather than because
+	// every vertex actually reached Passed/Failed. Some vertices may still
+	// be Pending; a later ExecuteWithContext call with a fresh context
+	// resumes it.
+	Aborted = "Aborted"
 )
 
 type Vertex struct {
 	ID      string
 	Status  Status
-	Loop    int
 	Dag     *Dag
 	CanFail bool
+
+	// Action is the work performed for this vertex. A nil Action is
+	// treated as an immediate success.
+	Action func(ctx context.Context) error
+	// Timeout, if non-zero, bounds a single Action invocation.
+	Timeout time.Duration
+
+	// Parallelism is how many copies of Action run concurrently for this
+	// vertex. Zero or negative behaves as 1.
+	Parallelism int
+	// Retry governs re-execution of Action after a failed attempt.
+	Retry RetryPolicy
+
+	// Group, if non-empty, is this vertex's auto-grouping key: AutoGroup
+	// may fuse it with an adjacent vertex that has the same key into a
+	// single composite vertex.
+	Group string
+}
+
+// RetryPolicy controls how many times, and with what backoff, a vertex's
+// Action is re-run after a failed attempt. Action is always invoked once;
+// on error it is re-run up to MaxAttempts further times, so the total
+// number of invocations is 1+MaxAttempts. A single successful attempt, at
+// any point, yields success; only exhausting every retry yields failure.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         bool
+}
+
+// nextBackoff computes the delay before the attempt after the given
+// zero-based attempt number: min(MaxBackoff, InitialBackoff *
+// Multiplier^attempt). When Jitter is set this uses equal jitter: the
+// backoff is halved, then a random amount up to the other half is added
+// back, so the result always falls in [0.5x, 1.0x] of the nominal value.
+func (r RetryPolicy) nextBackoff(attempt int) time.Duration {
+	if r.InitialBackoff <= 0 {
+		return 0
+	}
+
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+
+	backoff := float64(r.InitialBackoff) * math.Pow(multiplier, float64(attempt))
+	if r.MaxBackoff > 0 && backoff > float64(r.MaxBackoff) {
+		backoff = float64(r.MaxBackoff)
+	}
+
+	if r.Jitter {
+		backoff = backoff/2 + rand.Float64()*(backoff/2)
+	}
+
+	return time.Duration(backoff)
+}
+
+// GroupKey reports the key AutoGroup uses to decide whether this vertex
+// may be fused with an adjacent one. An empty key means "never group".
+func (v *Vertex) GroupKey() string {
+	return v.Group
+}
+
+// CanGroupWith reports whether v may be fused with other by AutoGroup.
+func (v *Vertex) CanGroupWith(other *Vertex) bool {
+	key := v.GroupKey()
+	return key != "" && key == other.GroupKey()
+}
+
+// Merge folds other into v: their Parallelism counts are summed, their
+// Actions are chained (v's, then other's), and the result can only fail
+// if both of the originals could. Merge does not combine Timeout or
+// Retry — the fused vertex keeps v's own values and other's are dropped,
+// so grouping a vertex with a per-step Timeout or a Retry policy into one
+// that has none loses that protection for the fused-away vertex's work.
+// Give every vertex in a Group the same Timeout/Retry if that matters.
+func (v *Vertex) Merge(other *Vertex) {
+	v.Parallelism = v.parallelism() + other.parallelism()
+	v.CanFail = v.CanFail && other.CanFail
+
+	first, second := v.Action, other.Action
+	v.Action = func(ctx context.Context) error {
+		if first != nil {
+			if err := first(ctx); err != nil {
+				return err
+			}
+		}
+		if second != nil {
+			return second(ctx)
+		}
+		return nil
+	}
 }
 
 func (v *Vertex) Id() string {
 	return v.ID
 }
 
-func (v *Vertex) SetPass() {
-	v.Dag.mu.Lock()
-	defer v.Dag.mu.Unlock()
-	if !(v.Dag.HasFailed()) {
-		v.Status = Passed
+// parallelism returns how many copies of Action should run concurrently:
+// Parallelism if positive, otherwise 1.
+func (v *Vertex) parallelism() int {
+	if v.Parallelism <= 0 {
+		return 1
+	}
+	return v.Parallelism
+}
+
+// run executes the vertex's Action once, honoring ctx cancellation and
+// the vertex's own Timeout.
+func (v *Vertex) run(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if v.Action == nil {
+		return nil
+	}
+
+	if v.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.Timeout)
+		defer cancel()
 	}
+
+	return v.Action(ctx)
 }
 
+// runWithRetry invokes Action once, and on error re-runs it up to
+// Retry.MaxAttempts further times according to Retry's backoff policy, so
+// the total number of invocations is 1+MaxAttempts. A zero (or negative)
+// MaxAttempts means a single attempt with no retries. It returns the
+// number of attempts made alongside the final error (nil on success), so
+// that callers running concurrent copies of the same vertex (Parallelism
+// > 1) each carry their own count rather than sharing mutable state on
+// the Vertex.
+func (v *Vertex) runWithRetry(ctx context.Context) (int, error) {
+	retries := v.Retry.MaxAttempts
+	if retries < 0 {
+		retries = 0
+	}
+	attempts := retries + 1
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		err = v.run(ctx)
+		if err == nil {
+			return attempt + 1, nil
+		}
+		if attempt == attempts-1 {
+			return attempt + 1, err
+		}
+
+		select {
+		case <-time.After(v.Retry.nextBackoff(attempt)):
+		case <-ctx.Done():
+			return attempt + 1, ctx.Err()
+		}
+	}
+
+	return attempts, err
+}
+
+// SetPass marks the vertex Passed if it is still Pending and the DAG has
+// not already failed; otherwise it does nothing.
+func (v *Vertex) SetPass() {
+	v.complete(Passed, 1)
+}
+
+// SetFail marks the vertex Failed if it is still Pending and the DAG has
+// not already failed; otherwise it does nothing. If the vertex cannot
+// fail, this also fails and cancels the whole DAG.
 func (v *Vertex) SetFail() {
+	v.complete(Failed, 1)
+}
+
+// complete is the shared implementation behind SetPass and SetFail.
+// attempt is the number of Action invocations that led to this
+// completion, reported on the Event that fires when the status actually
+// changes; SetPass/SetFail (called directly, outside of ExecuteWithContext)
+// report 1 since they don't know about any retry loop. A vertex only ever
+// transitions once: this is gated on the vertex's own previous Status
+// being Pending, not just the DAG's overall status, so that concurrent
+// copies of the same vertex (Parallelism > 1) don't each fire their own
+// OnVertexComplete/Event for what is logically a single completion.
+func (v *Vertex) complete(status Status, attempt int) {
 	v.Dag.mu.Lock()
-	defer v.Dag.mu.Unlock()
-	if !(v.Dag.HasFailed()) {
-		v.Status = Failed
-		if !v.CanFail {
+	old := v.Status
+	changed := old == Pending && !v.Dag.HasFailed()
+	if changed {
+		v.Status = status
+		if status == Failed && !v.CanFail {
 			println("Vertex: " + v.Id() + " failed, but it cannot fail.")
 			v.Dag.Status = Failed
+			if v.Dag.cancel != nil {
+				v.Dag.cancel()
+			}
+		}
+	}
+	onComplete := v.Dag.OnVertexComplete
+	v.Dag.mu.Unlock()
+
+	if changed {
+		v.Dag.emitEvent(v, old, status, attempt)
+		if onComplete != nil {
+			onComplete(v)
 		}
 	}
 }
@@ -57,35 +252,201 @@ type Dag struct {
 	mu                  sync.Mutex
 	Status              Status
 	IsStarted           bool
+	cancel              context.CancelFunc
+	maxParallelism      int
+
+	// OnVertexComplete, if set, is called once each time a vertex's status
+	// actually changes (a SetPass/SetFail on a vertex that has already left
+	// Pending does not call it), so callers can flush a checkpoint
+	// incrementally as the DAG progresses.
+	OnVertexComplete func(*Vertex)
+
+	events chan Event
 }
 
-func (d *Dag) Next() []Vertex {
-	d.IsStarted = true
+// Event records a single vertex status transition, as reported on the
+// channel returned by Dag.Events.
+type Event struct {
+	VertexID  string
+	OldStatus Status
+	NewStatus Status
+	Timestamp time.Time
+	Attempt   int
+}
 
-	if d.HasFailed() {
-		panic("DAG has failed")
+// Events returns a channel that receives an Event every time a vertex's
+// status changes via SetPass or SetFail. Call it before executing the
+// DAG so no transitions are missed. Sends are non-blocking: a slow or
+// absent consumer drops events rather than stalling vertex execution.
+func (d *Dag) Events() <-chan Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.events == nil {
+		d.events = make(chan Event, 64)
 	}
+	return d.events
+}
 
-	var nextVertices []Vertex
-	for _, v := range d.Vertices {
-		if d.CanExecute(v) {
-			nextVertices = append(nextVertices, *v)
-		}
+func (d *Dag) emitEvent(v *Vertex, oldStatus, newStatus Status, attempt int) {
+	d.mu.Lock()
+	ch := d.events
+	d.mu.Unlock()
+
+	if ch == nil {
+		return
+	}
+
+	event := Event{
+		VertexID:  v.ID,
+		OldStatus: oldStatus,
+		NewStatus: newStatus,
+		Timestamp: time.Now(),
+		Attempt:   attempt,
+	}
+
+	select {
+	case ch <- event:
+	default:
 	}
-	return nextVertices
 }
 
-func (d *Dag) CanExecute(v *Vertex) bool {
-	for _, parentID := range d.ConnectionsParents[v.ID] {
-		parent := d.Vertices[parentID]
-		if parent == nil || parent.Status == Pending {
-			return false
+// ToDOT writes a Graphviz DOT representation of the DAG to w, with
+// vertices colored by status (pending/passed/failed) and edges following
+// ConnectionsParents. This is meant for debugging cyclic-edge panics and
+// for visualizing a DAG's shape and progress.
+func (d *Dag) ToDOT(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := fmt.Fprintln(w, "digraph dag {"); err != nil {
+		return err
+	}
+
+	for id, v := range d.Vertices {
+		color := "lightgray"
+		switch v.Status {
+		case Passed:
+			color = "palegreen"
+		case Failed:
+			color = "salmon"
+		}
+		if _, err := fmt.Fprintf(w, "\t%q [style=filled, fillcolor=%s];\n", id, color); err != nil {
+			return err
 		}
 	}
-	if v.Status == Pending {
-		return true
+
+	for id, parents := range d.ConnectionsParents {
+		for _, parent := range parents {
+			if _, err := fmt.Fprintf(w, "\t%q -> %q;\n", parent, id); err != nil {
+				return err
+			}
+		}
 	}
-	return false
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// SetMaxParallelism caps the number of vertices a Walk will run
+// simultaneously. n <= 0 means unlimited.
+func (d *Dag) SetMaxParallelism(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxParallelism = n
+}
+
+// MultiError aggregates every error produced by a Walk so callers can see
+// all failures instead of only the first one encountered.
+type MultiError struct {
+	Errors []error
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ErrorOrNil returns m if it holds at least one error, otherwise nil.
+func (m *MultiError) ErrorOrNil() error {
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+// WalkFunc is invoked once per vertex, after all of that vertex's parents
+// have been walked.
+type WalkFunc func(v *Vertex) error
+
+// Walk runs fn over every vertex in the DAG in dependency order: a vertex's
+// WalkFunc starts as soon as all of its parents have finished, without
+// waiting on unrelated siblings. Concurrency is bounded by
+// SetMaxParallelism. Errors from every vertex are collected into a
+// MultiError and returned together rather than failing fast on the first
+// one; ctx cancellation still stops vertices that haven't started yet.
+func (d *Dag) Walk(ctx context.Context, fn WalkFunc) error {
+	d.IsStarted = true
+
+	d.mu.Lock()
+	maxParallelism := d.maxParallelism
+	d.mu.Unlock()
+
+	var sem chan struct{}
+	if maxParallelism > 0 {
+		sem = make(chan struct{}, maxParallelism)
+	}
+
+	done := make(map[string]chan struct{}, len(d.Vertices))
+	for id := range d.Vertices {
+		done[id] = make(chan struct{})
+	}
+
+	var (
+		wg    sync.WaitGroup
+		errMu sync.Mutex
+		errs  MultiError
+	)
+
+	for _, v := range d.Vertices {
+		wg.Add(1)
+		go func(vertex *Vertex) {
+			defer wg.Done()
+			defer close(done[vertex.ID])
+
+			for _, parentID := range d.ConnectionsParents[vertex.ID] {
+				select {
+				case <-done[parentID]:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if err := fn(vertex); err != nil {
+				errMu.Lock()
+				errs.Errors = append(errs.Errors, fmt.Errorf("vertex %s: %w", vertex.ID, err))
+				errMu.Unlock()
+			}
+		}(v)
+	}
+
+	wg.Wait()
+	return errs.ErrorOrNil()
 }
 
 func (d *Dag) HasFailed() bool {
@@ -96,10 +457,33 @@ func (d *Dag) HasSucceeded() bool {
 	return d.Status == Passed
 }
 
+// HasAborted reports whether ExecuteWithContext exited early due to ctx
+// cancellation or timeout, leaving some vertices unfinished. Unlike
+// HasFailed/HasSucceeded, this does not count as finished: a later
+// ExecuteWithContext call with a fresh context will resume the DAG.
+func (d *Dag) HasAborted() bool {
+	return d.Status == Aborted
+}
+
+// HasFinished reports whether the DAG reached a terminal outcome — Passed
+// or Failed. An Aborted DAG is deliberately excluded so that
+// ExecuteWithContext's early-return guard doesn't treat a canceled/timed
+// out run as done; calling it again resumes the remaining Pending
+// vertices.
 func (d *Dag) HasFinished() bool {
 	return d.HasFailed() || d.HasSucceeded()
 }
 
+// allVerticesFinished reports whether every vertex has left Pending.
+func (d *Dag) allVerticesFinished() bool {
+	for _, v := range d.Vertices {
+		if v.Status == Pending {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *Dag) AddVertex(v *Vertex) {
 	if d.IsStarted {
 		panic("Cannot add vertex to a DAG that has already started")
@@ -113,52 +497,481 @@ func (d *Dag) AddEdge(from, to string) {
 		panic("Cannot add edge to a DAG that has already started")
 	}
 
-	if d.IsCyclic(from, to) {
-		panic("Cannot add cyclic edge " + from + " -> " + to)
-	}
-
 	d.ConnectionsParents[to] = append(d.ConnectionsParents[to], from)
 	d.ConnectionsChildren[from] = append(d.ConnectionsChildren[from], to)
+
+	if cycle := d.FindCycle(); cycle != nil {
+		d.ConnectionsParents[to] = d.ConnectionsParents[to][:len(d.ConnectionsParents[to])-1]
+		d.ConnectionsChildren[from] = d.ConnectionsChildren[from][:len(d.ConnectionsChildren[from])-1]
+		panic("Cannot add cyclic edge " + from + " -> " + to + ", would create cycle among: " + strings.Join(cycle, ", "))
+	}
 }
 
-func (d *Dag) IsCyclic(from, to string) bool {
-	for _, child := range d.ConnectionsChildren[to] {
-		if child == from {
-			return true
+// FindCycle reports a cycle in the DAG, if one exists, as the IDs of the
+// vertices in the first cyclic strongly connected component found. It
+// returns nil if the DAG is acyclic. This runs Tarjan's strongly connected
+// components algorithm over ConnectionsChildren: any SCC containing more
+// than one vertex, or a single vertex with a self-loop, is a cycle. The
+// IDs are in the order they are popped off Tarjan's stack, which does not
+// necessarily follow the direction of the edges.
+func (d *Dag) FindCycle() []string {
+	index := 0
+	indices := make(map[string]int, len(d.Vertices))
+	lowlink := make(map[string]int, len(d.Vertices))
+	onStack := make(map[string]bool, len(d.Vertices))
+	var stack []string
+	var cycle []string
+
+	var strongconnect func(v string)
+	strongconnect = func(v string) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range d.ConnectionsChildren[v] {
+			if _, visited := indices[w]; !visited {
+				strongconnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] {
+				if indices[w] < lowlink[v] {
+					lowlink[v] = indices[w]
+				}
+			}
+		}
+
+		if lowlink[v] != indices[v] {
+			return
+		}
+
+		var scc []string
+		for {
+			n := len(stack) - 1
+			w := stack[n]
+			stack = stack[:n]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+
+		if cycle == nil && (len(scc) > 1 || d.hasSelfLoop(scc[0])) {
+			cycle = scc
 		}
-		if d.IsCyclic(from, child) {
+	}
+
+	for id := range d.Vertices {
+		if _, visited := indices[id]; !visited {
+			strongconnect(id)
+		}
+	}
+
+	return cycle
+}
+
+func (d *Dag) hasSelfLoop(id string) bool {
+	for _, child := range d.ConnectionsChildren[id] {
+		if child == id {
 			return true
 		}
 	}
 	return false
 }
 
-func (d *Dag) ExecuteVertices() {
-	for !d.HasFinished() {
-		verticesToProcess := d.Next()
+// AutoGroup fuses adjacent vertices that share a GroupKey into a single
+// composite vertex, combining their Parallelism counts and Actions. This lets
+// callers declare many small tasks (e.g. "install package X", "install
+// package Y") and have the DAG collapse them into one batched execution
+// at runtime, cutting per-task overhead. It must run before the DAG
+// starts; a fusion that would introduce a cycle is skipped.
+func (d *Dag) AutoGroup() {
+	if d.IsStarted {
+		panic("Cannot auto-group a DAG that has already started")
+	}
 
-		if verticesToProcess == nil {
-			d.Status = Passed
+	for d.groupOnce() {
+	}
+}
+
+// groupOnce performs at most one fusion and reports whether it happened.
+func (d *Dag) groupOnce() bool {
+	for parentID, children := range d.ConnectionsChildren {
+		parent := d.Vertices[parentID]
+		if parent == nil {
+			continue
 		}
 
-		var wg sync.WaitGroup
-		for _, v := range verticesToProcess {
-			wg.Add(v.Loop)
-			for i := 0; i < v.Loop; i++ {
-				go func(vertex *Vertex) {
-					defer wg.Done()
-					//executing vertex
-					if time.Now().Nanosecond()%2 == 0 {
-						d.Vertices[vertex.Id()].SetPass()
-					} else {
-						d.Vertices[vertex.Id()].SetFail()
-					}
-				}(&v)
+		for _, childID := range children {
+			child := d.Vertices[childID]
+			if child == nil || !parent.CanGroupWith(child) {
+				continue
+			}
+
+			if d.fuse(parentID, childID) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fuse merges childID into parentID, rewiring every edge that touched
+// childID onto parentID. It rolls back and returns false if the result
+// would contain a cycle.
+func (d *Dag) fuse(parentID, childID string) bool {
+	snapshotParents := cloneEdges(d.ConnectionsParents)
+	snapshotChildren := cloneEdges(d.ConnectionsChildren)
+
+	for _, p := range d.ConnectionsParents[childID] {
+		if p != parentID {
+			d.ConnectionsParents[parentID] = appendUnique(d.ConnectionsParents[parentID], p)
+			d.ConnectionsChildren[p] = replaceString(d.ConnectionsChildren[p], childID, parentID)
+		}
+	}
+	for _, c := range d.ConnectionsChildren[childID] {
+		if c != parentID {
+			d.ConnectionsChildren[parentID] = appendUnique(d.ConnectionsChildren[parentID], c)
+			d.ConnectionsParents[c] = replaceString(d.ConnectionsParents[c], childID, parentID)
+		}
+	}
+	d.ConnectionsChildren[parentID] = removeString(d.ConnectionsChildren[parentID], childID)
+	delete(d.ConnectionsParents, childID)
+	delete(d.ConnectionsChildren, childID)
+
+	if cycle := d.FindCycle(); cycle != nil {
+		d.ConnectionsParents = snapshotParents
+		d.ConnectionsChildren = snapshotChildren
+		return false
+	}
+
+	d.Vertices[parentID].Merge(d.Vertices[childID])
+	delete(d.Vertices, childID)
+	return true
+}
+
+func cloneEdges(edges map[string][]string) map[string][]string {
+	clone := make(map[string][]string, len(edges))
+	for id, ids := range edges {
+		clone[id] = append([]string(nil), ids...)
+	}
+	return clone
+}
+
+func appendUnique(ids []string, id string) []string {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+func removeString(ids []string, id string) []string {
+	out := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+func replaceString(ids []string, old, newID string) []string {
+	out := make([]string, 0, len(ids))
+	for _, existing := range ids {
+		if existing == old {
+			existing = newID
+		}
+		out = appendUnique(out, existing)
+	}
+	return out
+}
+
+// Roots returns every vertex with no parents.
+func (d *Dag) Roots() []*Vertex {
+	var roots []*Vertex
+	for id, v := range d.Vertices {
+		if len(d.ConnectionsParents[id]) == 0 {
+			roots = append(roots, v)
+		}
+	}
+	return roots
+}
+
+// Leaves returns every vertex with no children.
+func (d *Dag) Leaves() []*Vertex {
+	var leaves []*Vertex
+	for id, v := range d.Vertices {
+		if len(d.ConnectionsChildren[id]) == 0 {
+			leaves = append(leaves, v)
+		}
+	}
+	return leaves
+}
+
+// TopologicalSort returns every vertex ordered so that each vertex comes
+// after all of its parents. It returns an error if the DAG contains a
+// cycle.
+func (d *Dag) TopologicalSort() ([]*Vertex, error) {
+	if cycle := d.FindCycle(); cycle != nil {
+		return nil, fmt.Errorf("dag contains a cycle among: %s", strings.Join(cycle, ", "))
+	}
+
+	inDegree := make(map[string]int, len(d.Vertices))
+	for id := range d.Vertices {
+		inDegree[id] = len(d.ConnectionsParents[id])
+	}
+
+	var queue []string
+	for id, n := range inDegree {
+		if n == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	sorted := make([]*Vertex, 0, len(d.Vertices))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		sorted = append(sorted, d.Vertices[id])
+
+		for _, child := range d.ConnectionsChildren[id] {
+			inDegree[child]--
+			if inDegree[child] == 0 {
+				queue = append(queue, child)
 			}
 		}
+	}
+
+	return sorted, nil
+}
+
+// TransitiveReduction removes edges implied by a longer path through the
+// DAG (e.g. if A->B, B->C, and A->C all exist, A->C is dropped). It runs
+// in O(V*E): for each vertex it DFSes through its children's children to
+// find everything reachable via a path of length >= 2, then prunes any
+// direct edge to a vertex reachable that way.
+func (d *Dag) TransitiveReduction() {
+	for id, children := range d.ConnectionsChildren {
+		if len(children) == 0 {
+			continue
+		}
 
+		reachable := make(map[string]bool)
+		for _, child := range children {
+			d.markReachable(child, reachable)
+		}
+
+		kept := children[:0:0]
+		for _, child := range children {
+			if !reachable[child] {
+				kept = append(kept, child)
+			}
+		}
+		d.ConnectionsChildren[id] = kept
+	}
+
+	for id := range d.ConnectionsParents {
+		d.ConnectionsParents[id] = nil
+	}
+	for from, children := range d.ConnectionsChildren {
+		for _, to := range children {
+			d.ConnectionsParents[to] = append(d.ConnectionsParents[to], from)
+		}
+	}
+}
+
+// markReachable DFSes from v's children (not v itself) over
+// ConnectionsChildren, recording every vertex reachable from v via a path
+// of length >= 1, which is every vertex reachable from v's parent via a
+// path of length >= 2 through v.
+func (d *Dag) markReachable(v string, reachable map[string]bool) {
+	for _, child := range d.ConnectionsChildren[v] {
+		if !reachable[child] {
+			reachable[child] = true
+			d.markReachable(child, reachable)
+		}
+	}
+}
+
+// ExecuteVertices runs the DAG to completion using a background context.
+func (d *Dag) ExecuteVertices() {
+	d.ExecuteWithContext(context.Background())
+}
+
+// ExecuteWithContext runs the DAG to completion as a dependency-driven
+// parallel walk: each vertex's Action starts as soon as its parents have
+// passed or failed, bounded by SetMaxParallelism, rather than waiting on
+// unrelated siblings. Action runs once per Parallelism copy, retried per
+// the vertex's Retry policy, with the final error mapped to SetFail (nil
+// to SetPass). If a vertex that cannot fail does fail, the walk is
+// canceled for every vertex still running or yet to start. Vertices
+// already Passed or Failed (e.g. from a LoadCheckpoint) are skipped
+// rather than re-run. If ctx is canceled or times out before every
+// vertex finishes, the Dag's Status is set to Aborted rather than Passed;
+// call ExecuteWithContext again with a fresh context to resume the
+// vertices left Pending.
+func (d *Dag) ExecuteWithContext(ctx context.Context) {
+	if d.HasFinished() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	d.mu.Lock()
+	d.cancel = cancel
+	d.mu.Unlock()
+	defer cancel()
+
+	_ = d.Walk(ctx, func(vertex *Vertex) error {
+		if vertex.Status != Pending {
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		copies := vertex.parallelism()
+		wg.Add(copies)
+		for i := 0; i < copies; i++ {
+			go func() {
+				defer wg.Done()
+				attempt, err := vertex.runWithRetry(ctx)
+				if err != nil && ctx.Err() != nil {
+					// The walk was canceled mid-run: leave the vertex
+					// Pending so a later call can run it again.
+					return
+				}
+				if err != nil {
+					d.Vertices[vertex.Id()].complete(Failed, attempt)
+				} else {
+					d.Vertices[vertex.Id()].complete(Passed, attempt)
+				}
+			}()
+		}
 		wg.Wait()
+
+		if d.Vertices[vertex.ID].Status == Failed {
+			return fmt.Errorf("vertex %s failed", vertex.ID)
+		}
+		return nil
+	})
+
+	d.mu.Lock()
+	if d.Status == Pending || d.Status == Aborted {
+		if ctx.Err() == nil && d.allVerticesFinished() {
+			d.Status = Passed
+		} else {
+			d.Status = Aborted
+		}
 	}
+	d.mu.Unlock()
+}
+
+// dagSnapshot is the JSON-serializable form of a Dag, used by MarshalJSON,
+// UnmarshalJSON, SaveCheckpoint, and LoadCheckpoint.
+type dagSnapshot struct {
+	Status              Status              `json:"status"`
+	Vertices            []vertexSnapshot    `json:"vertices"`
+	ConnectionsParents  map[string][]string `json:"connections_parents"`
+	ConnectionsChildren map[string][]string `json:"connections_children"`
+}
+
+// vertexSnapshot is the JSON-serializable form of a Vertex. Action,
+// Timeout, Retry, and Group are not serialized, so a resumed Dag must
+// already have its vertices (with those fields set) added before
+// LoadCheckpoint restores status.
+type vertexSnapshot struct {
+	ID          string `json:"id"`
+	Status      Status `json:"status"`
+	Parallelism int    `json:"parallelism"`
+	CanFail     bool   `json:"can_fail"`
+}
+
+// MarshalJSON captures enough of the DAG's state — vertex IDs, statuses,
+// parallelism, CanFail, edges, and overall status — to be restored later
+// with UnmarshalJSON.
+func (d *Dag) MarshalJSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	snapshot := dagSnapshot{
+		Status:              d.Status,
+		ConnectionsParents:  d.ConnectionsParents,
+		ConnectionsChildren: d.ConnectionsChildren,
+	}
+	for _, v := range d.Vertices {
+		snapshot.Vertices = append(snapshot.Vertices, vertexSnapshot{
+			ID:          v.ID,
+			Status:      v.Status,
+			Parallelism: v.Parallelism,
+			CanFail:     v.CanFail,
+		})
+	}
+
+	return json.Marshal(snapshot)
+}
+
+// UnmarshalJSON restores DAG state previously produced by MarshalJSON.
+// Vertices already present on d (matched by ID) keep their Action,
+// Timeout, Retry, and Group; only their Status, Parallelism, and CanFail
+// are overwritten. Vertices not already present are added bare, with no
+// Action.
+func (d *Dag) UnmarshalJSON(data []byte) error {
+	var snapshot dagSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.Vertices == nil {
+		d.Vertices = make(map[string]*Vertex)
+	}
+
+	for _, vs := range snapshot.Vertices {
+		v, ok := d.Vertices[vs.ID]
+		if !ok {
+			v = &Vertex{ID: vs.ID, Dag: d}
+			d.Vertices[vs.ID] = v
+		}
+		v.Status = vs.Status
+		v.Parallelism = vs.Parallelism
+		v.CanFail = vs.CanFail
+	}
+
+	d.ConnectionsParents = snapshot.ConnectionsParents
+	d.ConnectionsChildren = snapshot.ConnectionsChildren
+	d.Status = snapshot.Status
+
+	return nil
+}
+
+// SaveCheckpoint writes the current DAG state to w as JSON so it can be
+// restored later with LoadCheckpoint.
+func (d *Dag) SaveCheckpoint(w io.Writer) error {
+	data, err := d.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// LoadCheckpoint restores DAG state previously written by SaveCheckpoint.
+// Call it after the DAG's vertices and edges have been declared. When
+// execution resumes, vertices already Passed or Failed are skipped and
+// each Pending vertex runs once all of its parents have finished; a
+// Pending vertex whose parent is Failed is run too, not skipped.
+func (d *Dag) LoadCheckpoint(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return d.UnmarshalJSON(data)
 }
 
 func NewDag() *Dag {
@@ -174,11 +987,18 @@ func NewDag() *Dag {
 func main() {
 	dag := NewDag()
 
-	vertexA := &Vertex{ID: "A", Status: Pending, CanFail: true, Loop: 1}
-	vertexB := &Vertex{ID: "B", Status: Pending, CanFail: false, Loop: 1}
-	vertexC := &Vertex{ID: "C", Status: Pending, CanFail: true, Loop: 11}
-	vertexD := &Vertex{ID: "D", Status: Pending, CanFail: true, Loop: 3}
-	vertexE := &Vertex{ID: "E", Status: Pending, CanFail: true, Loop: 1}
+	randomAction := func(ctx context.Context) error {
+		if time.Now().Nanosecond()%2 == 0 {
+			return nil
+		}
+		return fmt.Errorf("simulated failure")
+	}
+
+	vertexA := &Vertex{ID: "A", Status: Pending, CanFail: true, Parallelism: 1, Action: randomAction}
+	vertexB := &Vertex{ID: "B", Status: Pending, CanFail: false, Parallelism: 1, Action: randomAction}
+	vertexC := &Vertex{ID: "C", Status: Pending, CanFail: true, Parallelism: 11, Action: randomAction}
+	vertexD := &Vertex{ID: "D", Status: Pending, CanFail: true, Parallelism: 3, Action: randomAction}
+	vertexE := &Vertex{ID: "E", Status: Pending, CanFail: true, Parallelism: 1, Action: randomAction}
 
 	dag.AddVertex(vertexA)
 	dag.AddVertex(vertexB)
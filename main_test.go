@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWalkRunsInDependencyOrder(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A"})
+	d.AddVertex(&Vertex{ID: "B"})
+	d.AddVertex(&Vertex{ID: "C"})
+	d.AddEdge("A", "B")
+	d.AddEdge("B", "C")
+
+	var mu sync.Mutex
+	var order []string
+
+	err := d.Walk(context.Background(), func(v *Vertex) error {
+		mu.Lock()
+		order = append(order, v.ID)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk returned error: %v", err)
+	}
+	if len(order) != 3 || order[0] != "A" || order[1] != "B" || order[2] != "C" {
+		t.Fatalf("expected order [A B C], got %v", order)
+	}
+}
+
+func TestWalkCollectsErrorsFromEveryVertex(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A"})
+	d.AddVertex(&Vertex{ID: "B"})
+
+	err := d.Walk(context.Background(), func(v *Vertex) error {
+		return errors.New(v.ID + " failed")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("expected a *MultiError, got %T", err)
+	}
+	if len(multi.Errors) != 2 {
+		t.Fatalf("expected 2 collected errors, got %d: %v", len(multi.Errors), multi.Errors)
+	}
+}
+
+func TestRetryPolicyNextBackoff(t *testing.T) {
+	r := RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, MaxBackoff: time.Second}
+
+	if got := r.nextBackoff(0); got != 100*time.Millisecond {
+		t.Errorf("attempt 0: got %v, want 100ms", got)
+	}
+	if got := r.nextBackoff(1); got != 200*time.Millisecond {
+		t.Errorf("attempt 1: got %v, want 200ms", got)
+	}
+	if got := r.nextBackoff(10); got != time.Second {
+		t.Errorf("attempt 10: got %v, want capped at 1s", got)
+	}
+}
+
+func TestRetryPolicyJitterStaysWithinBounds(t *testing.T) {
+	r := RetryPolicy{InitialBackoff: 100 * time.Millisecond, Multiplier: 1, Jitter: true}
+
+	for i := 0; i < 50; i++ {
+		got := r.nextBackoff(0)
+		if got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of [50ms, 100ms]", got)
+		}
+	}
+}
+
+func TestRunWithRetrySucceedsAfterFailures(t *testing.T) {
+	var calls int
+	v := &Vertex{
+		ID: "A",
+		Action: func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+		Retry: RetryPolicy{MaxAttempts: 5},
+	}
+
+	attempts, err := v.runWithRetry(context.Background())
+	if err != nil {
+		t.Fatalf("runWithRetry returned error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRunWithRetryExhaustsMaxAttempts(t *testing.T) {
+	var calls int
+	v := &Vertex{
+		ID: "A",
+		Action: func(ctx context.Context) error {
+			calls++
+			return errors.New("always fails")
+		},
+		Retry: RetryPolicy{MaxAttempts: 2},
+	}
+
+	attempts, err := v.runWithRetry(context.Background())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 3 {
+		t.Errorf("expected 1+MaxAttempts == 3 attempts, got %d", attempts)
+	}
+	if calls != 3 {
+		t.Errorf("expected Action invoked 3 times, got %d", calls)
+	}
+}
+
+func TestExecuteWithContextTimeoutAborts(t *testing.T) {
+	d := NewDag()
+	slow := func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	d.AddVertex(&Vertex{ID: "A", Status: Pending, CanFail: true, Action: slow})
+	d.AddVertex(&Vertex{ID: "B", Status: Pending, CanFail: true, Action: slow})
+	d.AddEdge("A", "B")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	d.ExecuteWithContext(ctx)
+
+	if d.HasSucceeded() {
+		t.Fatal("expected the Dag not to report success after a timeout")
+	}
+	if !d.HasAborted() {
+		t.Fatalf("expected the Dag to be Aborted, got %v", d.Status)
+	}
+	if d.Vertices["B"].Status != Pending {
+		t.Fatalf("expected B to remain Pending after the timeout, got %v", d.Vertices["B"].Status)
+	}
+	if d.HasFinished() {
+		t.Fatal("an Aborted Dag must not report HasFinished, so a later ExecuteWithContext call can resume it")
+	}
+}
+
+func TestAutoGroupFusesSameGroupVertices(t *testing.T) {
+	d := NewDag()
+	var ranA, ranB bool
+	d.AddVertex(&Vertex{ID: "A", Group: "install", Parallelism: 2, CanFail: true, Action: func(ctx context.Context) error {
+		ranA = true
+		return nil
+	}})
+	d.AddVertex(&Vertex{ID: "B", Group: "install", Parallelism: 3, CanFail: true, Action: func(ctx context.Context) error {
+		ranB = true
+		return nil
+	}})
+	d.AddEdge("A", "B")
+
+	d.AutoGroup()
+
+	if len(d.Vertices) != 1 {
+		t.Fatalf("expected A and B to be fused into 1 vertex, got %d", len(d.Vertices))
+	}
+	merged, ok := d.Vertices["A"]
+	if !ok {
+		t.Fatal("expected the fused vertex to keep parent ID A")
+	}
+	if merged.Parallelism != 5 {
+		t.Errorf("expected fused Parallelism to be 2+3=5, got %d", merged.Parallelism)
+	}
+	if err := merged.Action(context.Background()); err != nil {
+		t.Fatalf("fused Action returned error: %v", err)
+	}
+	if !ranA || !ranB {
+		t.Errorf("expected fused Action to chain both originals, ranA=%v ranB=%v", ranA, ranB)
+	}
+}
+
+func TestAutoGroupSkipsFusionThatWouldCreateACycle(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A", Group: "g"})
+	d.AddVertex(&Vertex{ID: "B", Group: "g"})
+	d.AddVertex(&Vertex{ID: "X"})
+
+	// Wire A->B->X->A directly, bypassing AddEdge's own cycle check, so
+	// that fusing the adjacent same-Group A and B (which would rewrite
+	// B->X onto A, landing on top of the existing X->A edge) collapses
+	// the graph into a 2-cycle. AutoGroup/fuse must detect this and roll
+	// the fusion back rather than leave the DAG corrupted.
+	d.ConnectionsChildren["A"] = []string{"B"}
+	d.ConnectionsChildren["B"] = []string{"X"}
+	d.ConnectionsChildren["X"] = []string{"A"}
+	d.ConnectionsParents["B"] = []string{"A"}
+	d.ConnectionsParents["X"] = []string{"B"}
+	d.ConnectionsParents["A"] = []string{"X"}
+
+	d.AutoGroup()
+
+	if len(d.Vertices) != 3 {
+		t.Fatalf("expected the cycle-inducing fusion to be rejected, got %d vertices", len(d.Vertices))
+	}
+	if children := d.ConnectionsChildren["A"]; len(children) != 1 || children[0] != "B" {
+		t.Fatalf("expected edges to be rolled back to their original state, got %v", children)
+	}
+}
+
+func TestTransitiveReduction(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A"})
+	d.AddVertex(&Vertex{ID: "B"})
+	d.AddVertex(&Vertex{ID: "C"})
+	d.AddEdge("A", "B")
+	d.AddEdge("B", "C")
+	d.AddEdge("A", "C")
+
+	d.TransitiveReduction()
+
+	children := d.ConnectionsChildren["A"]
+	if len(children) != 1 || children[0] != "B" {
+		t.Fatalf("expected A's only remaining child to be B, got %v", children)
+	}
+	if parents := d.ConnectionsParents["C"]; len(parents) != 1 || parents[0] != "B" {
+		t.Fatalf("expected C's only remaining parent to be B, got %v", parents)
+	}
+}
+
+func TestToDOTContainsVerticesAndEdges(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A", Status: Pending})
+	d.AddVertex(&Vertex{ID: "B", Status: Passed})
+	d.AddEdge("A", "B")
+
+	var buf bytes.Buffer
+	if err := d.ToDOT(&buf); err != nil {
+		t.Fatalf("ToDOT: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, `"A" [style=filled, fillcolor=lightgray];`) {
+		t.Errorf("expected a Pending A node colored lightgray, got:\n%s", out)
+	}
+	if !strings.Contains(out, `"B" [style=filled, fillcolor=palegreen];`) {
+		t.Errorf("expected a Passed B node colored palegreen, got:\n%s", out)
+	}
+	if !strings.Contains(out, `"A" -> "B";`) {
+		t.Errorf("expected an A -> B edge, got:\n%s", out)
+	}
+}
+
+func TestEventsReceivesSetPassTransition(t *testing.T) {
+	d := NewDag()
+	v := &Vertex{ID: "A", Status: Pending}
+	d.AddVertex(v)
+
+	events := d.Events()
+	v.SetPass()
+
+	select {
+	case e := <-events:
+		if e.VertexID != "A" || e.OldStatus != Pending || e.NewStatus != Passed || e.Attempt != 1 {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	default:
+		t.Fatal("expected an Event after SetPass, got none")
+	}
+}
+
+func TestEventsDoesNotFireTwiceForOneVertex(t *testing.T) {
+	d := NewDag()
+	v := &Vertex{ID: "A", Status: Pending}
+	d.AddVertex(v)
+
+	events := d.Events()
+	v.SetPass()
+	v.SetPass()
+
+	if len(events) != 1 {
+		t.Fatalf("expected exactly 1 Event for one logical completion, got %d", len(events))
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	d := NewDag()
+	d.AddVertex(&Vertex{ID: "A", Parallelism: 2, CanFail: true})
+	d.AddVertex(&Vertex{ID: "B"})
+	d.AddEdge("A", "B")
+	d.Vertices["A"].Status = Passed
+
+	var buf bytes.Buffer
+	if err := d.SaveCheckpoint(&buf); err != nil {
+		t.Fatalf("SaveCheckpoint: %v", err)
+	}
+
+	restored := NewDag()
+	restored.AddVertex(&Vertex{ID: "A"})
+	restored.AddVertex(&Vertex{ID: "B"})
+	if err := restored.LoadCheckpoint(&buf); err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+
+	if restored.Vertices["A"].Status != Passed {
+		t.Errorf("expected A to be restored as Passed, got %v", restored.Vertices["A"].Status)
+	}
+	if restored.Vertices["A"].Parallelism != 2 || !restored.Vertices["A"].CanFail {
+		t.Errorf("expected A's Parallelism/CanFail to round-trip, got %+v", restored.Vertices["A"])
+	}
+	if parents := restored.ConnectionsParents["B"]; len(parents) != 1 || parents[0] != "A" {
+		t.Fatalf("expected B's parent edge to round-trip, got %v", parents)
+	}
+}